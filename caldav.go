@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/jordic/goics"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// davPrefix is the root of the CalDAV subsystem. Each token gets its own
+// calendar collection at davPrefix+token+"/".
+const davPrefix = "/dav/"
+
+// davNamespace is the CalDAV/WebDAV XML namespace used throughout the
+// multistatus responses below.
+const davNamespace = "DAV:"
+const caldavNamespace = "urn:ietf:params:xml:ns:caldav"
+
+// handleDAV dispatches the subset of RFC 4791 that calendar clients such
+// as Thunderbird, Apple Calendar and DAVx5 exercise: OPTIONS, PROPFIND
+// and REPORT. It shares the in-memory feed cache with the plain /feed/
+// handler so both subscription styles see the same data.
+func handleDAV(store Store) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.Trim(strings.TrimPrefix(r.URL.Path, davPrefix), "/")
+		if token == "" {
+			writeError(http.StatusNotFound, "No Token given", w, fmt.Errorf("no token given"))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodOptions:
+			handleDAVOptions(w)
+		case "PROPFIND":
+			handleDAVPropfind(store, token, w, r)
+		case "REPORT":
+			handleDAVReport(store, token, w, r)
+		default:
+			writeError(http.StatusMethodNotAllowed, "Method not supported", w, fmt.Errorf("method %s not supported", r.Method))
+		}
+	})
+}
+
+func handleDAVOptions(w http.ResponseWriter) {
+	w.Header().Set("DAV", "1, calendar-access")
+	w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT")
+	w.WriteHeader(http.StatusOK)
+}
+
+// multistatus and its children model just enough of RFC 4791/2518 to
+// answer the PROPFIND and REPORT requests below.
+type multistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"DAV: href"`
+	Propstat davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+type davProp struct {
+	ResourceType                  *davResourceType  `xml:"DAV: resourcetype"`
+	DisplayName                   string            `xml:"DAV: displayname,omitempty"`
+	GetETag                       string            `xml:"DAV: getetag,omitempty"`
+	GetCTag                       string            `xml:"http://calendarserver.org/ns/ getctag,omitempty"`
+	CalendarHomeSet               *davHref          `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set,omitempty"`
+	SupportedCalendarComponentSet *supportedCompSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set,omitempty"`
+	CalendarData                  string            `xml:"urn:ietf:params:xml:ns:caldav calendar-data,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+	Calendar   *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+type supportedCompSet struct {
+	Comp []davComp `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type davComp struct {
+	Name string `xml:"name,attr"`
+}
+
+func handleDAVPropfind(store Store, token string, w http.ResponseWriter, r *http.Request) {
+	feed, err := store.GetFeed(r.Context(), token)
+	if err != nil {
+		writeError(http.StatusNotFound, "No Feed for this Token", w, err)
+		return
+	}
+	loc, err := resolveTimezone(feed.TZID)
+	if err != nil {
+		writeError(http.StatusInternalServerError, "Could not load feed", w, err)
+		return
+	}
+
+	depth := r.Header.Get("Depth")
+	href := davPrefix + token + "/"
+
+	ms := multistatus{Responses: []davResponse{collectionResponse(href, token, feed)}}
+	if depth == "1" {
+		for _, entry := range feed.Entries {
+			ms.Responses = append(ms.Responses, eventResponse(href, entry, loc, feed.TZID))
+		}
+	}
+
+	writeMultistatus(w, ms)
+}
+
+func collectionResponse(href, token string, feed *Feed) davResponse {
+	return davResponse{
+		Href: href,
+		Propstat: davPropstat{
+			Status: "HTTP/1.1 200 OK",
+			Prop: davProp{
+				ResourceType:                  &davResourceType{Collection: &struct{}{}, Calendar: &struct{}{}},
+				DisplayName:                   "Calendar " + token,
+				GetCTag:                       feedCTag(feed),
+				CalendarHomeSet:               &davHref{Href: href},
+				SupportedCalendarComponentSet: &supportedCompSet{Comp: []davComp{{Name: "VEVENT"}}},
+			},
+		},
+	}
+}
+
+func eventResponse(collectionHref string, entry *Entry, loc *time.Location, tzid string) davResponse {
+	return davResponse{
+		Href: collectionHref + entry.UID + ".ics",
+		Propstat: davPropstat{
+			Status: "HTTP/1.1 200 OK",
+			Prop: davProp{
+				GetETag:      entry.ETag,
+				CalendarData: renderEntry(entry, loc, tzid),
+			},
+		},
+	}
+}
+
+// feedCTag gives clients a single value that changes whenever anything
+// in the collection changes, so they know to re-sync.
+func feedCTag(feed *Feed) string {
+	return strconv.FormatInt(feed.ExpiresAt.Add(-expirationTime).Unix(), 10)
+}
+
+// calendarQuery and calendarMultiget model the two REPORT bodies this
+// handler understands.
+type calendarQuery struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-query"`
+	Filter  struct {
+		CompFilter struct {
+			CompFilter struct {
+				TimeRange *struct {
+					Start string `xml:"start,attr"`
+					End   string `xml:"end,attr"`
+				} `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+			} `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+		} `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type calendarMultiget struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-multiget"`
+	Hrefs   []string `xml:"DAV: href"`
+}
+
+func handleDAVReport(store Store, token string, w http.ResponseWriter, r *http.Request) {
+	feed, err := store.GetFeed(r.Context(), token)
+	if err != nil {
+		writeError(http.StatusNotFound, "No Feed for this Token", w, err)
+		return
+	}
+	loc, err := resolveTimezone(feed.TZID)
+	if err != nil {
+		writeError(http.StatusInternalServerError, "Could not load feed", w, err)
+		return
+	}
+
+	body, err := readAll(r)
+	if err != nil {
+		writeError(http.StatusBadRequest, "Could not read request body", w, err)
+		return
+	}
+
+	href := davPrefix + token + "/"
+
+	var entries Entries
+	if bytes.Contains(body, []byte("calendar-multiget")) {
+		var mg calendarMultiget
+		if err := xml.Unmarshal(body, &mg); err != nil {
+			writeError(http.StatusBadRequest, "Invalid calendar-multiget body", w, err)
+			return
+		}
+		entries = entriesForHrefs(feed.Entries, mg.Hrefs)
+	} else {
+		var q calendarQuery
+		if err := xml.Unmarshal(body, &q); err != nil {
+			writeError(http.StatusBadRequest, "Invalid calendar-query body", w, err)
+			return
+		}
+		tr := q.Filter.CompFilter.CompFilter.TimeRange
+		entries = filterByTimeRange(feed.Entries, tr)
+	}
+
+	ms := multistatus{}
+	for _, entry := range entries {
+		ms.Responses = append(ms.Responses, eventResponse(href, entry, loc, feed.TZID))
+	}
+	writeMultistatus(w, ms)
+}
+
+func entriesForHrefs(entries Entries, hrefs []string) Entries {
+	var result Entries
+	for _, href := range hrefs {
+		for _, entry := range entries {
+			if strings.Contains(href, entry.UID) {
+				result = append(result, entry)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func filterByTimeRange(entries Entries, tr *struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}) Entries {
+	if tr == nil {
+		return entries
+	}
+	start, errStart := time.Parse("20060102T150405Z", tr.Start)
+	end, errEnd := time.Parse("20060102T150405Z", tr.End)
+	if errStart != nil || errEnd != nil {
+		return entries
+	}
+	var result Entries
+	for _, entry := range entries {
+		if entry.DateEnd.After(start) && entry.DateStart.Before(end) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// renderEntry encodes a single entry as its own VCALENDAR, the way
+// calendar-query/calendar-multiget responses expect calendar-data. It
+// goes through tzEntries, the same as /feed/{token}.ics, so a CalDAV
+// subscriber sees the same per-token timezone the feed was created with
+// instead of a floating time.
+func renderEntry(entry *Entry, loc *time.Location, tzid string) string {
+	b := bytes.Buffer{}
+	goics.NewICalEncode(&b).Encode(tzEntries{Entries: Entries{entry}, Loc: loc, TZID: tzid})
+	return b.String()
+}
+
+func writeMultistatus(w http.ResponseWriter, ms multistatus) {
+	out, err := xml.Marshal(ms)
+	if err != nil {
+		writeError(http.StatusInternalServerError, "Could not encode response", w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
+
+func readAll(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	buf := bytes.Buffer{}
+	_, err := buf.ReadFrom(r.Body)
+	return buf.Bytes(), err
+}
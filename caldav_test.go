@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntriesForHrefs(t *testing.T) {
+	entries := Entries{
+		{UID: "uid-1", Description: "standup"},
+		{UID: "uid-2", Description: "retro"},
+	}
+
+	result := entriesForHrefs(entries, []string{"/dav/tok/uid-2.ics"})
+	if len(result) != 1 || result[0].UID != "uid-2" {
+		t.Errorf("entriesForHrefs = %v, want only uid-2", result)
+	}
+}
+
+func TestFilterByTimeRangeNilRangeReturnsAll(t *testing.T) {
+	entries := Entries{{UID: "uid-1"}, {UID: "uid-2"}}
+	if got := filterByTimeRange(entries, nil); len(got) != 2 {
+		t.Errorf("filterByTimeRange(nil) = %v, want all entries", got)
+	}
+}
+
+func TestFilterByTimeRangeFiltersOverlapping(t *testing.T) {
+	entries := Entries{
+		{UID: "inside", DateStart: time.Date(2024, time.June, 1, 9, 0, 0, 0, time.UTC), DateEnd: time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC)},
+		{UID: "outside", DateStart: time.Date(2024, time.July, 1, 9, 0, 0, 0, time.UTC), DateEnd: time.Date(2024, time.July, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	tr := &struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	}{Start: "20240601T000000Z", End: "20240602T000000Z"}
+
+	result := filterByTimeRange(entries, tr)
+	if len(result) != 1 || result[0].UID != "inside" {
+		t.Errorf("filterByTimeRange = %v, want only the overlapping entry", result)
+	}
+}
+
+func TestFilterByTimeRangeInvalidDatesReturnsAll(t *testing.T) {
+	entries := Entries{{UID: "uid-1"}}
+	tr := &struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	}{Start: "not-a-date", End: "also-not-a-date"}
+
+	if got := filterByTimeRange(entries, tr); len(got) != 1 {
+		t.Errorf("filterByTimeRange with unparsable dates = %v, want all entries as a fallback", got)
+	}
+}
+
+func TestRenderEntryUsesFeedTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("could not load America/New_York: %v", err)
+	}
+	entry := &Entry{
+		DateStart: time.Date(2024, time.June, 1, 13, 0, 0, 0, time.UTC),
+		DateEnd:   time.Date(2024, time.June, 1, 14, 0, 0, 0, time.UTC),
+	}
+
+	// goics.WriteStringField upper-cases the whole "key" it's handed,
+	// which for a TZID-parameterized property includes the TZID value.
+	out := renderEntry(entry, loc, "America/New_York")
+	if !strings.Contains(out, "DTSTART;TZID=AMERICA/NEW_YORK:20240601T090000") {
+		t.Errorf("renderEntry did not render DTSTART in the feed's timezone, got:\n%s", out)
+	}
+	if !strings.Contains(out, "BEGIN:VTIMEZONE") {
+		t.Errorf("renderEntry did not carry a VTIMEZONE block for a non-UTC feed, got:\n%s", out)
+	}
+}
+
+func TestRenderEntryUTCIsAZInstant(t *testing.T) {
+	entry := &Entry{
+		DateStart: time.Date(2024, time.June, 1, 9, 0, 0, 0, time.UTC),
+		DateEnd:   time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	out := renderEntry(entry, time.UTC, "UTC")
+	if !strings.Contains(out, "DTSTART:20240601T090000Z") {
+		t.Errorf("renderEntry for a UTC feed did not render a Z instant, got:\n%s", out)
+	}
+}
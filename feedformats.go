@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/feeds"
+	"github.com/jordic/goics"
+	"time"
+)
+
+// Supported representations for a feed, selected via a filename suffix
+// on /feed/{token}, e.g. /feed/abc123.rss.
+const (
+	formatICS  = "ics"
+	formatRSS  = "rss"
+	formatAtom = "atom"
+	formatJSON = "json"
+)
+
+// representations holds every pre-rendered format for a Feed, so a
+// refresh renders once per format instead of on every request.
+type representations struct {
+	ICS  string
+	RSS  string
+	Atom string
+	JSON string
+}
+
+// get returns the rendered content for a format, and whether the format
+// is one this server knows how to render.
+func (r representations) get(format string) (string, bool) {
+	switch format {
+	case formatICS:
+		return r.ICS, true
+	case formatRSS:
+		return r.RSS, true
+	case formatAtom:
+		return r.Atom, true
+	case formatJSON:
+		return r.JSON, true
+	default:
+		return "", false
+	}
+}
+
+// all returns every rendered representation keyed by format, so a Store
+// can persist each one without knowing the representations struct shape.
+func (r representations) all() map[string]string {
+	return map[string]string{
+		formatICS:  r.ICS,
+		formatRSS:  r.RSS,
+		formatAtom: r.Atom,
+		formatJSON: r.JSON,
+	}
+}
+
+// contentTypeForFormat maps a requested format to the Content-type the
+// /feed/ handler should answer with.
+func contentTypeForFormat(format string) string {
+	switch format {
+	case formatRSS:
+		return "application/rss+xml"
+	case formatAtom:
+		return "application/atom+xml"
+	case formatJSON:
+		return "application/json"
+	default:
+		return "text/calendar"
+	}
+}
+
+// renderRepresentations renders entries into every supported format, so
+// the same token can be consumed by calendar apps and feed readers alike.
+func renderRepresentations(token string, entries Entries, loc *time.Location, tzid string) (representations, error) {
+	b := bytes.Buffer{}
+	goics.NewICalEncode(&b).Encode(tzEntries{Entries: entries, Loc: loc, TZID: tzid})
+
+	gf := feedForEntries(token, entries)
+
+	rss, err := gf.ToRss()
+	if err != nil {
+		return representations{}, fmt.Errorf("could not render rss: %w", err)
+	}
+	atom, err := gf.ToAtom()
+	if err != nil {
+		return representations{}, fmt.Errorf("could not render atom: %w", err)
+	}
+	jsonEntries, err := json.Marshal(entries)
+	if err != nil {
+		return representations{}, fmt.Errorf("could not render json: %w", err)
+	}
+
+	return representations{
+		ICS:  b.String(),
+		RSS:  rss,
+		Atom: atom,
+		JSON: string(jsonEntries),
+	}, nil
+}
+
+// feedForEntries maps Entries onto a gorilla/feeds Feed, the shared
+// model it renders to both RSS 2.0 and Atom 1.0.
+func feedForEntries(token string, entries Entries) *feeds.Feed {
+	link := &feeds.Link{Href: feedPrefix + token}
+	f := &feeds.Feed{
+		Title:   "Calendar Feed " + token,
+		Link:    link,
+		Created: time.Now(),
+	}
+	for _, entry := range entries {
+		f.Items = append(f.Items, &feeds.Item{
+			Title:   entry.Description,
+			Link:    link,
+			Id:      entry.UID,
+			Created: entry.DateStart,
+			Updated: entry.DateEnd,
+		})
+	}
+	return f
+}
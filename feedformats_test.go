@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTokenAndFormat(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantToken  string
+		wantFormat string
+	}{
+		{"/feed/abc123", "abc123", formatICS},
+		{"/feed/abc123.ics", "abc123", formatICS},
+		{"/feed/abc123.rss", "abc123", formatRSS},
+		{"/feed/abc123.atom", "abc123", formatAtom},
+		{"/feed/abc123.json", "abc123", formatJSON},
+	}
+	for _, tt := range tests {
+		token, format := parseTokenAndFormat(tt.path)
+		if token != tt.wantToken || format != tt.wantFormat {
+			t.Errorf("parseTokenAndFormat(%q) = (%q, %q), want (%q, %q)", tt.path, token, format, tt.wantToken, tt.wantFormat)
+		}
+	}
+}
+
+func TestContentTypeForFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{formatICS, "text/calendar"},
+		{formatRSS, "application/rss+xml"},
+		{formatAtom, "application/atom+xml"},
+		{formatJSON, "application/json"},
+		{"unknown", "text/calendar"},
+	}
+	for _, tt := range tests {
+		if got := contentTypeForFormat(tt.format); got != tt.want {
+			t.Errorf("contentTypeForFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestRepresentationsGet(t *testing.T) {
+	reps := representations{ICS: "ics-content", RSS: "rss-content", Atom: "atom-content", JSON: "json-content"}
+
+	tests := []struct {
+		format string
+		want   string
+		wantOK bool
+	}{
+		{formatICS, "ics-content", true},
+		{formatRSS, "rss-content", true},
+		{formatAtom, "atom-content", true},
+		{formatJSON, "json-content", true},
+		{"unknown", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := reps.get(tt.format)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("representations.get(%q) = (%q, %v), want (%q, %v)", tt.format, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRenderRepresentationsAllFormats(t *testing.T) {
+	entries := Entries{{
+		DateStart:   time.Date(2024, time.June, 1, 9, 0, 0, 0, time.UTC),
+		DateEnd:     time.Date(2024, time.June, 1, 10, 0, 0, 0, time.UTC),
+		Description: "standup",
+		UID:         "uid-1",
+	}}
+
+	reps, err := renderRepresentations("tok", entries, nil, "UTC")
+	if err != nil {
+		t.Fatalf("could not render representations: %v", err)
+	}
+	if reps.ICS == "" || reps.RSS == "" || reps.Atom == "" || reps.JSON == "" {
+		t.Errorf("expected every representation to be non-empty, got %+v", reps)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// holidaySource computes holiday events for a country/locale instead of
+// fetching them from a remote API, modeled on the fixed-date and
+// movable-date routing a typical holiday API exposes.
+type holidaySource struct {
+	Country string
+}
+
+// Fetch implements Source
+func (h holidaySource) Fetch(ctx context.Context, from, to time.Time) (Entries, error) {
+	var entries Entries
+	for year := from.Year(); year <= to.Year(); year++ {
+		entries = append(entries, holidaysForYear(h.Country, year)...)
+	}
+	return entriesInRange(entries, from, to), nil
+}
+
+// holidaysForYear returns the known holidays for a country in a given
+// year. Unrecognized countries yield no holidays rather than an error,
+// since "holidays" staying enabled with an unsupported country should
+// just contribute nothing to the merged feed.
+func holidaysForYear(country string, year int) Entries {
+	switch country {
+	case "us":
+		return usHolidays(year)
+	default:
+		return nil
+	}
+}
+
+func usHolidays(year int) Entries {
+	return Entries{
+		fixedHoliday(year, time.January, 1, "New Year's Day"),
+		fixedHoliday(year, time.July, 4, "Independence Day"),
+		fixedHoliday(year, time.December, 25, "Christmas Day"),
+		easterHoliday(year),
+		thanksgivingHoliday(year),
+	}
+}
+
+func fixedHoliday(year int, month time.Month, day int, description string) *Entry {
+	start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &Entry{DateStart: start, DateEnd: start.Add(24 * time.Hour), Description: description}
+}
+
+// easterHoliday computes Easter Sunday via the Anonymous Gregorian
+// algorithm.
+func easterHoliday(year int) *Entry {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	start := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return &Entry{DateStart: start, DateEnd: start.Add(24 * time.Hour), Description: "Easter Sunday"}
+}
+
+// thanksgivingHoliday returns the 4th Thursday of November.
+func thanksgivingHoliday(year int) *Entry {
+	start := nthWeekdayOfMonth(year, time.November, time.Thursday, 4)
+	return &Entry{DateStart: start, DateEnd: start.Add(24 * time.Hour), Description: "Thanksgiving Day"}
+}
+
+// nthWeekdayOfMonth finds the nth occurrence of a weekday in a month,
+// e.g. the 4th Thursday of November.
+func nthWeekdayOfMonth(year int, month time.Month, weekday time.Weekday, nth int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	day := 1 + offset + (nth-1)*7
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// entriesInRange keeps only entries overlapping [from, to), the way
+// fetchMergedEntries expects every Source to filter its own output.
+func entriesInRange(entries Entries, from, to time.Time) Entries {
+	var result Entries
+	for _, entry := range entries {
+		if entry.DateEnd.After(from) && entry.DateStart.Before(to) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEasterHoliday(t *testing.T) {
+	tests := []struct {
+		year int
+		want time.Time
+	}{
+		{2023, time.Date(2023, time.April, 9, 0, 0, 0, 0, time.UTC)},
+		{2024, time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{2025, time.Date(2025, time.April, 20, 0, 0, 0, 0, time.UTC)},
+		{2026, time.Date(2026, time.April, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		entry := easterHoliday(tt.year)
+		if !entry.DateStart.Equal(tt.want) {
+			t.Errorf("easterHoliday(%d) = %s, want %s", tt.year, entry.DateStart, tt.want)
+		}
+	}
+}
+
+func TestThanksgivingHoliday(t *testing.T) {
+	tests := []struct {
+		year int
+		want time.Time
+	}{
+		{2023, time.Date(2023, time.November, 23, 0, 0, 0, 0, time.UTC)},
+		{2024, time.Date(2024, time.November, 28, 0, 0, 0, 0, time.UTC)},
+		{2025, time.Date(2025, time.November, 27, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		entry := thanksgivingHoliday(tt.year)
+		if !entry.DateStart.Equal(tt.want) {
+			t.Errorf("thanksgivingHoliday(%d) = %s, want %s", tt.year, entry.DateStart, tt.want)
+		}
+	}
+}
+
+func TestHolidaysForYearUnsupportedCountry(t *testing.T) {
+	if got := holidaysForYear("xx", 2024); got != nil {
+		t.Errorf("holidaysForYear for an unsupported country = %v, want nil", got)
+	}
+}
+
+func TestEntriesInRange(t *testing.T) {
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	entries := Entries{
+		fixedHoliday(2024, time.January, 1, "inside"),
+		fixedHoliday(2024, time.March, 1, "outside"),
+	}
+
+	result := entriesInRange(entries, from, to)
+	if len(result) != 1 || result[0].Description != "inside" {
+		t.Errorf("entriesInRange = %v, want only the entry inside [from, to)", result)
+	}
+}
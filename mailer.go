@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Environment variables configuring the SMTP digest mode.
+const (
+	envSMTPHost  = "ICAL_SMTP_HOST"
+	envSMTPPort  = "ICAL_SMTP_PORT"
+	envSMTPUser  = "ICAL_SMTP_USER"
+	envSMTPPass  = "ICAL_SMTP_PASS"
+	envSMTPFrom  = "ICAL_SMTP_FROM"
+	envDryRunDir = "ICAL_MAIL_DRY_RUN_DIR"
+)
+
+// MailConfig holds everything sendDigest needs to reach a relay. When
+// DryRunDir is non-empty, digests are written to disk as .eml files
+// instead of being sent, for testing without a real mailbox.
+type MailConfig struct {
+	Host      string
+	Port      string
+	User      string
+	Pass      string
+	From      string
+	DryRunDir string
+}
+
+// mailConfigFromEnv reads SMTP relay/auth settings from the environment.
+// Setting ICAL_MAIL_DRY_RUN_DIR switches to dry-run mode regardless of
+// whether the other variables are set.
+func mailConfigFromEnv() MailConfig {
+	return MailConfig{
+		Host:      os.Getenv(envSMTPHost),
+		Port:      os.Getenv(envSMTPPort),
+		User:      os.Getenv(envSMTPUser),
+		Pass:      os.Getenv(envSMTPPass),
+		From:      os.Getenv(envSMTPFrom),
+		DryRunDir: os.Getenv(envDryRunDir),
+	}
+}
+
+// sendDigest renders and delivers (or, in dry-run mode, writes to disk)
+// the digest email for a single subscription, then records its
+// Message-ID so the next digest can reference it for threading.
+func sendDigest(cfg MailConfig, store Store, sub Subscription, feed *Feed) error {
+	msg, msgID, err := buildDigestMessage(cfg, sub, feed)
+	if err != nil {
+		return fmt.Errorf("could not build digest message: %w", err)
+	}
+
+	if cfg.DryRunDir != "" {
+		if err := writeDigestEml(cfg.DryRunDir, sub.Token, msg); err != nil {
+			return err
+		}
+	} else {
+		addr := cfg.Host + ":" + cfg.Port
+		auth := smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
+		if err := smtp.SendMail(addr, auth, cfg.From, []string{sub.Email}, msg); err != nil {
+			return err
+		}
+	}
+
+	if err := store.RecordMessageID(context.Background(), sub.Token, msgID); err != nil {
+		return fmt.Errorf("could not record message id: %w", err)
+	}
+	return nil
+}
+
+// buildDigestMessage composes a multipart/mixed RFC 5322 message: a
+// multipart/alternative text/plain+text/html summary, plus the rendered
+// feed attached as application/ics. The Message-ID is derived from the
+// token and current time; when sub.LastMessageID carries a prior digest's
+// Message-ID, it's set as References so mail clients thread successive
+// digests for the same token.
+func buildDigestMessage(cfg MailConfig, sub Subscription, feed *Feed) ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	msgID := fmt.Sprintf("<%s.%d@example-go-ical>", sub.Token, time.Now().UnixNano())
+
+	buf.WriteString("From: " + cfg.From + "\r\n")
+	buf.WriteString("To: " + sub.Email + "\r\n")
+	buf.WriteString("Subject: Your calendar digest\r\n")
+	buf.WriteString("Message-ID: " + msgID + "\r\n")
+	if sub.LastMessageID != "" {
+		buf.WriteString("References: " + sub.LastMessageID + "\r\n")
+	}
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	mixedWriter := multipart.NewWriter(&buf)
+	buf.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixedWriter.Boundary()))
+
+	altPart, err := buildAlternativePart(feed)
+	if err != nil {
+		return nil, "", err
+	}
+	altHeader := textproto.MIMEHeader{"Content-Type": {altPart.contentType}}
+	part, err := mixedWriter.CreatePart(altHeader)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create alternative part: %w", err)
+	}
+	if _, err := part.Write(altPart.body); err != nil {
+		return nil, "", fmt.Errorf("could not write alternative part: %w", err)
+	}
+
+	icsHeader := textproto.MIMEHeader{
+		"Content-Type":        {`application/ics; method=REQUEST; name="calendar.ics"`},
+		"Content-Disposition": {`attachment; filename="calendar.ics"`},
+	}
+	icsPart, err := mixedWriter.CreatePart(icsHeader)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create calendar attachment: %w", err)
+	}
+	if _, err := icsPart.Write([]byte(feed.Representations.ICS)); err != nil {
+		return nil, "", fmt.Errorf("could not write calendar attachment: %w", err)
+	}
+
+	if err := mixedWriter.Close(); err != nil {
+		return nil, "", fmt.Errorf("could not close digest message: %w", err)
+	}
+	return buf.Bytes(), msgID, nil
+}
+
+type mimePart struct {
+	contentType string
+	body        []byte
+}
+
+// buildAlternativePart renders the plain-text and HTML summaries of
+// upcoming events as a nested multipart/alternative part.
+func buildAlternativePart(feed *Feed) (mimePart, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	plainPart, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return mimePart{}, fmt.Errorf("could not create plain-text part: %w", err)
+	}
+	if _, err := plainPart.Write([]byte(digestPlainText(feed.Entries))); err != nil {
+		return mimePart{}, fmt.Errorf("could not write plain-text part: %w", err)
+	}
+
+	htmlPart, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+	if err != nil {
+		return mimePart{}, fmt.Errorf("could not create html part: %w", err)
+	}
+	if _, err := htmlPart.Write([]byte(digestHTML(feed.Entries))); err != nil {
+		return mimePart{}, fmt.Errorf("could not write html part: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return mimePart{}, fmt.Errorf("could not close alternative part: %w", err)
+	}
+
+	return mimePart{
+		contentType: fmt.Sprintf("multipart/alternative; boundary=%s", w.Boundary()),
+		body:        buf.Bytes(),
+	}, nil
+}
+
+func digestPlainText(entries Entries) string {
+	var b strings.Builder
+	b.WriteString("Upcoming events:\n\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "- %s (%s - %s)\n", entry.Description, entry.DateStart.Format(time.RFC1123), entry.DateEnd.Format(time.RFC1123))
+	}
+	return b.String()
+}
+
+func digestHTML(entries Entries) string {
+	var b strings.Builder
+	b.WriteString("<html><body><h1>Upcoming events</h1><ul>")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "<li>%s (%s - %s)</li>", entry.Description, entry.DateStart.Format(time.RFC1123), entry.DateEnd.Format(time.RFC1123))
+	}
+	b.WriteString("</ul></body></html>")
+	return b.String()
+}
+
+// writeDigestEml writes a rendered digest to dir/token.eml, so the SMTP
+// path can be exercised in tests and local development without a relay.
+func writeDigestEml(dir, token string, msg []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("could not create dry-run dir: %w", err)
+	}
+	path := filepath.Join(dir, token+".eml")
+	if err := os.WriteFile(path, msg, 0o644); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func testFeed() *Feed {
+	return &Feed{
+		Entries: Entries{{
+			Description: "standup",
+		}},
+		Representations: representations{ICS: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+	}
+}
+
+func TestBuildDigestMessageNoReferencesWithoutPriorMessageID(t *testing.T) {
+	sub := Subscription{Token: "tok", Email: "a@example.com"}
+	msg, msgID, err := buildDigestMessage(MailConfig{From: "cal@example.com"}, sub, testFeed())
+	if err != nil {
+		t.Fatalf("could not build digest message: %v", err)
+	}
+	if msgID == "" {
+		t.Errorf("expected a non-empty Message-ID")
+	}
+	if strings.Contains(string(msg), "References:") {
+		t.Errorf("expected no References header for a token's first digest, got:\n%s", msg)
+	}
+}
+
+func TestBuildDigestMessageReferencesPriorMessageID(t *testing.T) {
+	sub := Subscription{Token: "tok", Email: "a@example.com", LastMessageID: "<tok.111@example-go-ical>"}
+	msg, _, err := buildDigestMessage(MailConfig{From: "cal@example.com"}, sub, testFeed())
+	if err != nil {
+		t.Fatalf("could not build digest message: %v", err)
+	}
+	if !strings.Contains(string(msg), "References: <tok.111@example-go-ical>\r\n") {
+		t.Errorf("expected References to chain to the prior Message-ID, got:\n%s", msg)
+	}
+}
+
+func TestBuildDigestMessageMIMEStructure(t *testing.T) {
+	sub := Subscription{Token: "tok", Email: "a@example.com"}
+	msg, _, err := buildDigestMessage(MailConfig{From: "cal@example.com"}, sub, testFeed())
+	if err != nil {
+		t.Fatalf("could not build digest message: %v", err)
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(strings.NewReader(string(msg))))
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("could not parse headers: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("expected a multipart/mixed top-level message, got %q (err %v)", mediaType, err)
+	}
+
+	body, err := io.ReadAll(tp.R)
+	if err != nil {
+		t.Fatalf("could not read message body: %v", err)
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	altPart, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("could not read alternative part: %v", err)
+	}
+	altMediaType, _, err := mime.ParseMediaType(altPart.Header.Get("Content-Type"))
+	if err != nil || altMediaType != "multipart/alternative" {
+		t.Errorf("expected first part to be multipart/alternative, got %q (err %v)", altMediaType, err)
+	}
+
+	icsPart, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("could not read calendar attachment part: %v", err)
+	}
+	if !strings.Contains(icsPart.Header.Get("Content-Type"), "application/ics") {
+		t.Errorf("expected the calendar attachment to be application/ics, got %q", icsPart.Header.Get("Content-Type"))
+	}
+}
+
+func TestDigestPlainTextAndHTMLListEntries(t *testing.T) {
+	entries := Entries{{Description: "standup"}}
+	if !strings.Contains(digestPlainText(entries), "standup") {
+		t.Errorf("expected digestPlainText to mention the entry description")
+	}
+	if !strings.Contains(digestHTML(entries), "standup") {
+		t.Errorf("expected digestHTML to mention the entry description")
+	}
+}
@@ -1,13 +1,11 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/rand"
-	"encoding/json"
+	"crypto/sha1"
 	"errors"
 	"fmt"
-	"github.com/jordic/goics"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"strings"
@@ -16,11 +14,17 @@ import (
 
 const feedPrefix = "/feed/"
 const expirationTime = 5 * time.Minute
+const sqliteDSN = "calendar.db"
+const purgeInterval = time.Minute
 
-// Feed is an iCal feed
+// Feed is a calendar feed, pre-rendered in every supported format
 type Feed struct {
-	Content   string
-	ExpiresAt time.Time
+	Representations representations
+	Entries         Entries
+	TZID            string
+	Country         string
+	Sources         []string
+	ExpiresAt       time.Time
 }
 
 // Entry is a time entry
@@ -28,26 +32,83 @@ type Entry struct {
 	DateStart   time.Time `json:"dateStart"`
 	DateEnd     time.Time `json:"dateEnd"`
 	Description string    `json:"description"`
+	UID         string    `json:"-"`
+	ETag        string    `json:"-"`
 }
 
 // Entries is a collection of entries
 type Entries []*Entry
 
 func main() {
-	cache := make(map[string]*Feed)
+	store, err := NewSQLiteStore(sqliteDSN)
+	if err != nil {
+		log.Fatal("could not open store: ", err)
+	}
+
+	go purgeExpiredLoop(store)
+
+	mailCfg := mailConfigFromEnv()
+	digestCron := startDigestScheduler(store, mailCfg)
+	defer digestCron.Stop()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/feedURL", feedURL(cache))
-	mux.HandleFunc(feedPrefix, feed(cache))
+	mux.HandleFunc("/feedURL", feedURL(store))
+	mux.HandleFunc(feedPrefix, feed(store))
+	mux.HandleFunc(davPrefix, handleDAV(store))
 
 	log.Print("Server started on localhost:8080")
 	log.Fatal(http.ListenAndServe(":8080", mux))
 }
 
-func feedURL(cache map[string]*Feed) http.HandlerFunc {
+// purgeExpiredLoop periodically vacuums expired feed_cache rows so the
+// database doesn't grow unbounded with stale representations.
+func purgeExpiredLoop(store Store) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := store.PurgeExpired(context.Background()); err != nil {
+			log.Print("ERROR: could not purge expired feeds: ", err)
+		}
+	}
+}
+
+func feedURL(store Store) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := randomToken(20)
-		_, err := createFeedForToken(token, cache)
+		tzid := r.URL.Query().Get("tz")
+		if tzid == "" {
+			tzid = defaultTZID
+		}
+		loc, err := resolveTimezone(tzid)
+		if err != nil {
+			writeError(http.StatusBadRequest, "Unsupported timezone", w, err)
+			return
+		}
+
+		email := r.URL.Query().Get("email")
+		schedule := r.URL.Query().Get("schedule")
+		if email != "" {
+			if _, ok := scheduleToCronSpec(schedule); !ok {
+				writeError(http.StatusBadRequest, "Unsupported schedule", w, fmt.Errorf("unsupported schedule: %s", schedule))
+				return
+			}
+		}
+
+		country := r.URL.Query().Get("country")
+		if country == "" {
+			country = defaultCountry
+		}
+		sources, err := parseSources(r.URL.Query().Get("sources"))
+		if err != nil {
+			writeError(http.StatusBadRequest, "Unsupported source", w, err)
+			return
+		}
+
+		token, err := store.CreateToken(r.Context(), TokenOptions{TZID: tzid, Email: email, Schedule: schedule, Country: country, Sources: sources})
+		if err != nil {
+			writeError(http.StatusInternalServerError, "Could not create token", w, err)
+			return
+		}
+		_, err = createFeedForToken(r.Context(), token, loc, tzid, country, sources, store)
 		if err != nil {
 			writeError(http.StatusInternalServerError, "Could not create feed", w, err)
 			return
@@ -56,92 +117,131 @@ func feedURL(cache map[string]*Feed) http.HandlerFunc {
 	})
 }
 
-func feed(cache map[string]*Feed) http.HandlerFunc {
+// parseSources validates a comma-separated ?sources= value, falling
+// back to defaultSources when none is given.
+func parseSources(raw string) ([]string, error) {
+	if raw == "" {
+		return defaultSources, nil
+	}
+	names := strings.Split(raw, ",")
+	for _, name := range names {
+		if _, ok := sourceForName(name, defaultCountry); !ok {
+			return nil, fmt.Errorf("unsupported source: %s", name)
+		}
+	}
+	return names, nil
+}
+
+func feed(store Store) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-type", "text/calendar")
-		w.Header().Set("charset", "utf-8")
-		w.Header().Set("Content-Disposition", "inline")
-		w.Header().Set("filename", "calendar.ics")
-
-		var result string
-		token := parseToken(r.URL.Path)
-		log.Print("Fetching iCal feed for Token: " + token)
-		feed, ok := cache[token]
-		if !ok || feed == nil {
-			writeError(http.StatusNotFound, "No Feed for this Token", w, errors.New("No Feed for this Token"))
+		token, format := parseTokenAndFormat(r.URL.Path)
+		log.Print("Fetching " + format + " feed for Token: " + token)
+
+		feed, err := store.GetFeed(r.Context(), token)
+		if errors.Is(err, ErrTokenNotFound) {
+			writeError(http.StatusNotFound, "No Feed for this Token", w, err)
+			return
+		} else if err != nil {
+			writeError(http.StatusInternalServerError, "Could not load feed", w, err)
 			return
 		}
 
-		result = feed.Content
+		reps := feed.Representations
 		if feed.ExpiresAt.Before(time.Now()) {
-			newFeed, err := createFeedForToken(token, cache)
+			loc, err := resolveTimezone(feed.TZID)
+			if err != nil {
+				writeError(http.StatusInternalServerError, "Could not load feed", w, err)
+				return
+			}
+			newFeed, err := createFeedForToken(r.Context(), token, loc, feed.TZID, feed.Country, feed.Sources, store)
 			if err != nil {
 				writeError(http.StatusInternalServerError, "Could not create feed", w, err)
 				return
 			}
-			result = newFeed.Content
+			reps = newFeed.Representations
+		}
+
+		result, ok := reps.get(format)
+		if !ok {
+			writeError(http.StatusNotFound, "Unsupported format", w, fmt.Errorf("unsupported format: %s", format))
+			return
 		}
 
+		w.Header().Set("Content-type", contentTypeForFormat(format))
+		w.Header().Set("charset", "utf-8")
+		if format == formatICS {
+			w.Header().Set("Content-Disposition", "inline")
+			w.Header().Set("filename", "calendar.ics")
+		}
 		writeSuccess(result, w)
 	})
 }
 
-func createFeedForToken(token string, cache map[string]*Feed) (*Feed, error) {
-	res, err := fetchData()
+func createFeedForToken(ctx context.Context, token string, loc *time.Location, tzid string, country string, sources []string, store Store) (*Feed, error) {
+	res, err := fetchMergedEntries(ctx, sources, country)
 	if err != nil {
-		return nil, errors.New("Could not fetch data")
+		return nil, fmt.Errorf("could not fetch data: %w", err)
 	}
-	b := bytes.Buffer{}
-	goics.NewICalEncode(&b).Encode(res)
-	feed := &Feed{Content: b.String(), ExpiresAt: time.Now().Add(expirationTime)}
-	cache[token] = feed
-	return feed, nil
-}
 
-func fetchData() (Entries, error) {
-	url := "http://www.mocky.io/v2/5a88375b3000007e007f9401"
-	resp, err := http.Get(url)
+	reps, err := renderRepresentations(token, res, loc, tzid)
 	if err != nil {
-		return nil, errors.New("could not fetch data")
+		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("%s: %s", "could not fetch data", resp.Status)
+
+	feed := &Feed{
+		Representations: reps,
+		Entries:         res,
+		TZID:            tzid,
+		Country:         country,
+		Sources:         sources,
+		ExpiresAt:       time.Now().Add(expirationTime),
 	}
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.New("could not read data")
+	if err := store.SaveFeed(ctx, token, feed); err != nil {
+		return nil, fmt.Errorf("could not save feed: %w", err)
 	}
-	result := Entries{}
-	err = json.Unmarshal(b, &result)
-	if err != nil {
-		return nil, errors.New("could not unmarshal data")
+	return feed, nil
+}
+
+// annotateEntries fills in a stable UID and an ETag for every entry so
+// CalDAV clients can address and conditionally sync individual events.
+func annotateEntries(entries Entries) {
+	for _, entry := range entries {
+		entry.UID = entryUID(entry)
+		entry.ETag = entryETag(entry)
 	}
-	return result, nil
+}
 
+// entryUID derives a stable identifier from the fields that define an
+// event, so the same logical entry keeps its UID across fetches.
+func entryUID(entry *Entry) string {
+	h := sha1.New()
+	h.Write([]byte(entry.Description))
+	h.Write([]byte(entry.DateStart.UTC().Format(time.RFC3339)))
+	return fmt.Sprintf("%x@example-go-ical", h.Sum(nil))
 }
 
-// EmitICal implements the interface for goics
-func (e Entries) EmitICal() goics.Componenter {
-	c := goics.NewComponent()
-	c.SetType("VCALENDAR")
-	c.AddProperty("CALSCAL", "GREGORIAN")
-	for _, entry := range e {
-		s := goics.NewComponent()
-		s.SetType("VEVENT")
-		k, v := goics.FormatDateTimeField("DTEND", entry.DateEnd)
-		s.AddProperty(k, v)
-		k, v = goics.FormatDateTimeField("DTSTART", entry.DateStart)
-		s.AddProperty(k, v)
-		s.AddProperty("SUMMARY", entry.Description)
-
-		c.AddComponent(s)
-	}
-	return c
+// entryETag hashes the full entry content, so any change to it (start,
+// end or description) produces a new ETag for conditional requests.
+func entryETag(entry *Entry) string {
+	h := sha1.New()
+	h.Write([]byte(entry.Description))
+	h.Write([]byte(entry.DateStart.UTC().Format(time.RFC3339)))
+	h.Write([]byte(entry.DateEnd.UTC().Format(time.RFC3339)))
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
 }
 
-func parseToken(path string) string {
-	return strings.TrimPrefix(path, feedPrefix)
+// parseTokenAndFormat splits a request path into its token and requested
+// format, e.g. "/feed/abc123.rss" -> ("abc123", "rss"). A token with no
+// recognized suffix is treated as the default iCal format.
+func parseTokenAndFormat(path string) (string, string) {
+	token := strings.TrimPrefix(path, feedPrefix)
+	for _, format := range []string{formatICS, formatRSS, formatAtom, formatJSON} {
+		suffix := "." + format
+		if strings.HasSuffix(token, suffix) {
+			return strings.TrimSuffix(token, suffix), format
+		}
+	}
+	return token, formatICS
 }
 
 func randomToken(len int) string {
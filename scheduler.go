@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"github.com/robfig/cron/v3"
+	"log"
+)
+
+// digestSchedules are the ?schedule= values feedURL accepts, each
+// mapped to a robfig/cron spec.
+var digestSchedules = map[string]string{
+	"hourly": "@hourly",
+	"daily":  "@daily",
+	"weekly": "@weekly",
+}
+
+func scheduleToCronSpec(schedule string) (string, bool) {
+	spec, ok := digestSchedules[schedule]
+	return spec, ok
+}
+
+// startDigestScheduler registers one cron job per supported schedule
+// and starts running them. Each job re-queries the store for tokens on
+// that cadence, so newly created subscriptions are picked up without a
+// restart.
+func startDigestScheduler(store Store, cfg MailConfig) *cron.Cron {
+	c := cron.New()
+	for schedule, spec := range digestSchedules {
+		schedule := schedule
+		if _, err := c.AddFunc(spec, func() { runDigests(context.Background(), store, cfg, schedule) }); err != nil {
+			log.Fatalf("could not schedule %s digests: %v", schedule, err)
+		}
+	}
+	c.Start()
+	return c
+}
+
+// runDigests mails a fresh feed to every subscription on the given
+// schedule.
+func runDigests(ctx context.Context, store Store, cfg MailConfig, schedule string) {
+	subs, err := store.ListSubscriptions(ctx)
+	if err != nil {
+		log.Print("ERROR: could not list digest subscriptions: ", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if sub.Schedule != schedule {
+			continue
+		}
+
+		loc, err := resolveTimezone(sub.TZID)
+		if err != nil {
+			log.Print("ERROR: could not resolve timezone for digest: ", err)
+			continue
+		}
+
+		feed, err := createFeedForToken(ctx, sub.Token, loc, sub.TZID, sub.Country, sub.Sources, store)
+		if err != nil {
+			log.Print("ERROR: could not render digest feed: ", err)
+			continue
+		}
+
+		if err := sendDigest(cfg, store, sub, feed); err != nil {
+			log.Print("ERROR: could not send digest to ", sub.Email, ": ", err)
+		}
+	}
+}
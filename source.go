@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// sourceWork and sourceHolidays are the ?sources= values feedURL
+// accepts. sourceWork is enabled by default, reproducing the server's
+// original single-source behavior.
+const (
+	sourceWork     = "work"
+	sourceHolidays = "holidays"
+)
+
+// defaultSources is what a token gets when ?sources= is omitted.
+var defaultSources = []string{sourceWork}
+
+// defaultCountry is used for the holidays source when ?country= is
+// omitted.
+const defaultCountry = "us"
+
+// Source fetches calendar entries for a time window, so a feed can be
+// assembled from more than one origin.
+type Source interface {
+	Fetch(ctx context.Context, from, to time.Time) (Entries, error)
+}
+
+// sourceForName resolves a ?sources= entry to its Source implementation.
+func sourceForName(name, country string) (Source, bool) {
+	switch name {
+	case sourceWork:
+		return workSource{}, true
+	case sourceHolidays:
+		return holidaySource{Country: country}, true
+	default:
+		return nil, false
+	}
+}
+
+// fetchMergedEntries fetches entries from every enabled source and
+// merges them into one deduplicated set.
+func fetchMergedEntries(ctx context.Context, sources []string, country string) (Entries, error) {
+	from, to := feedWindow()
+
+	seen := map[string]bool{}
+	var merged Entries
+	for _, name := range sources {
+		source, ok := sourceForName(name, country)
+		if !ok {
+			continue
+		}
+		entries, err := source.Fetch(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		annotateEntries(entries)
+		for _, entry := range entries {
+			if seen[entry.UID] {
+				continue
+			}
+			seen[entry.UID] = true
+			merged = append(merged, entry)
+		}
+	}
+	return merged, nil
+}
+
+// feedWindow is the time range sources are asked to fill: a week of
+// look-back plus a year of look-ahead.
+func feedWindow() (time.Time, time.Time) {
+	now := time.Now()
+	return now.AddDate(0, 0, -7), now.AddDate(1, 0, 0)
+}
+
+// workSource is the original mocky.io-backed data source.
+type workSource struct{}
+
+func (workSource) Fetch(ctx context.Context, from, to time.Time) (Entries, error) {
+	return fetchData()
+}
+
+func fetchData() (Entries, error) {
+	url := "http://www.mocky.io/v2/5a88375b3000007e007f9401"
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, errors.New("could not fetch data")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("could not fetch data: " + resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New("could not read data")
+	}
+	result := Entries{}
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		return nil, errors.New("could not unmarshal data")
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTokenNotFound is returned by Store.GetFeed when no token (or no
+// cached feed for that token yet) exists.
+var ErrTokenNotFound = errors.New("no feed for this token")
+
+// TokenOptions configures a newly created subscription token.
+type TokenOptions struct {
+	TZID string
+	// Email and Schedule opt the token into the SMTP digest mode; both
+	// are empty for a plain subscribe-and-poll token.
+	Email    string
+	Schedule string
+	// Country and Sources select which Source implementations feed
+	// this token's entries.
+	Country string
+	Sources []string
+}
+
+// Subscription is a token's email digest settings, as handed to the
+// scheduler so it knows who to mail, how often and from which sources.
+type Subscription struct {
+	Token    string
+	TZID     string
+	Email    string
+	Schedule string
+	Country  string
+	Sources  []string
+	// LastMessageID is the Message-ID of the last digest sent for this
+	// token, if any, so the next digest can reference it for threading.
+	LastMessageID string
+}
+
+// Store persists subscription tokens and their rendered feed
+// representations, so subscriptions and their caches survive a restart
+// and are safe to use from concurrent HTTP handlers.
+type Store interface {
+	// CreateToken registers a new subscription token with the given
+	// options and returns it.
+	CreateToken(ctx context.Context, opts TokenOptions) (string, error)
+	// GetFeed loads the cached feed for a token, including its
+	// timezone and every pre-rendered representation.
+	GetFeed(ctx context.Context, token string) (*Feed, error)
+	// SaveFeed persists a freshly rendered feed for a token, replacing
+	// whatever was cached for it before.
+	SaveFeed(ctx context.Context, token string, feed *Feed) error
+	// PurgeExpired removes every cached representation whose
+	// expiration has passed.
+	PurgeExpired(ctx context.Context) error
+	// ListSubscriptions returns every token that opted into the SMTP
+	// digest mode, for the scheduler to mail on their chosen cadence.
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	// RecordMessageID saves the Message-ID of a digest just sent for a
+	// token, so the next digest can chain its References to it.
+	RecordMessageID(ctx context.Context, token, messageID string) error
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqliteSchema is applied on every startup; CREATE TABLE IF NOT EXISTS
+// keeps it safe to re-run, which is this package's migration strategy
+// until the schema needs something more involved than adding tables.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tokens (
+	token TEXT PRIMARY KEY,
+	tz TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	schedule TEXT NOT NULL DEFAULT '',
+	country TEXT NOT NULL DEFAULT 'us',
+	sources TEXT NOT NULL DEFAULT 'work',
+	created_at DATETIME NOT NULL,
+	last_fetched_at DATETIME NOT NULL,
+	last_message_id TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS feed_cache (
+	token TEXT NOT NULL,
+	format TEXT NOT NULL,
+	content TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	PRIMARY KEY (token, format)
+);
+`
+
+// SQLiteStore is a Store backed by modernc.org/sqlite, so the binary
+// stays CGO-free. Writes go through a RWMutex in addition to SQLite's
+// own locking, since multiple HTTP handlers may call in concurrently.
+type SQLiteStore struct {
+	db *sql.DB
+	mu sync.RWMutex
+
+	insertToken      *sql.Stmt
+	touchToken       *sql.Stmt
+	selectToken      *sql.Stmt
+	upsertCache      *sql.Stmt
+	selectCache      *sql.Stmt
+	purgeCache       *sql.Stmt
+	selectSubscribed *sql.Stmt
+	updateMessageID  *sql.Stmt
+}
+
+// NewSQLiteStore opens (creating if necessary) the sqlite database at
+// dsn, runs migrations and prepares every statement the store needs.
+func NewSQLiteStore(dsn string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sqlite db: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("could not run migrations: %w", err)
+	}
+
+	s := &SQLiteStore{db: db}
+	prepared := []struct {
+		query string
+		dst   **sql.Stmt
+	}{
+		{"INSERT INTO tokens (token, tz, email, schedule, country, sources, created_at, last_fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", &s.insertToken},
+		{"UPDATE tokens SET last_fetched_at = ? WHERE token = ?", &s.touchToken},
+		{"SELECT tz, country, sources FROM tokens WHERE token = ?", &s.selectToken},
+		{`INSERT INTO feed_cache (token, format, content, etag, expires_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(token, format) DO UPDATE SET
+				content = excluded.content, etag = excluded.etag, expires_at = excluded.expires_at`, &s.upsertCache},
+		{"SELECT format, content, etag, expires_at FROM feed_cache WHERE token = ?", &s.selectCache},
+		{"DELETE FROM feed_cache WHERE expires_at < ?", &s.purgeCache},
+		{"SELECT token, tz, email, schedule, country, sources, last_message_id FROM tokens WHERE email != ''", &s.selectSubscribed},
+		{"UPDATE tokens SET last_message_id = ? WHERE token = ?", &s.updateMessageID},
+	}
+	for _, p := range prepared {
+		stmt, err := db.Prepare(p.query)
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare statement: %w", err)
+		}
+		*p.dst = stmt
+	}
+
+	return s, nil
+}
+
+// CreateToken implements Store
+func (s *SQLiteStore) CreateToken(ctx context.Context, opts TokenOptions) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := randomToken(20)
+	now := time.Now()
+	country := opts.Country
+	if country == "" {
+		country = defaultCountry
+	}
+	sources := opts.Sources
+	if len(sources) == 0 {
+		sources = defaultSources
+	}
+	if _, err := s.insertToken.ExecContext(ctx, token, opts.TZID, opts.Email, opts.Schedule, country, strings.Join(sources, ","), now, now); err != nil {
+		return "", fmt.Errorf("could not create token: %w", err)
+	}
+	return token, nil
+}
+
+// ListSubscriptions implements Store
+func (s *SQLiteStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.selectSubscribed.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		var sources string
+		if err := rows.Scan(&sub.Token, &sub.TZID, &sub.Email, &sub.Schedule, &sub.Country, &sources, &sub.LastMessageID); err != nil {
+			return nil, fmt.Errorf("could not scan subscription row: %w", err)
+		}
+		sub.Sources = strings.Split(sources, ",")
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// GetFeed implements Store
+func (s *SQLiteStore) GetFeed(ctx context.Context, token string) (*Feed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tzid, country, sourcesCSV string
+	if err := s.selectToken.QueryRowContext(ctx, token).Scan(&tzid, &country, &sourcesCSV); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("could not load token: %w", err)
+	}
+	sources := strings.Split(sourcesCSV, ",")
+
+	rows, err := s.selectCache.QueryContext(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("could not load feed cache: %w", err)
+	}
+	defer rows.Close()
+
+	var reps representations
+	var entriesJSON string
+	var expiresAt time.Time
+	for rows.Next() {
+		var format, content, etag string
+		var expires time.Time
+		if err := rows.Scan(&format, &content, &etag, &expires); err != nil {
+			return nil, fmt.Errorf("could not scan feed cache row: %w", err)
+		}
+		expiresAt = expires
+		switch format {
+		case formatICS:
+			reps.ICS = content
+		case formatRSS:
+			reps.RSS = content
+		case formatAtom:
+			reps.Atom = content
+		case formatJSON:
+			reps.JSON = content
+		case storageFormatEntries:
+			entriesJSON = content
+		}
+	}
+
+	// A token row with no feed_cache rows is a real subscription whose
+	// cache PurgeExpired has since vacuumed, not a token that never
+	// existed. Reporting ExpiresAt as the zero value (always "before
+	// now") tells callers to refresh rather than 404.
+	var entries Entries
+	if entriesJSON != "" {
+		decoded, err := decodeStoredEntries(entriesJSON)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode cached entries: %w", err)
+		}
+		entries = decoded
+	}
+
+	return &Feed{Representations: reps, Entries: entries, TZID: tzid, Country: country, Sources: sources, ExpiresAt: expiresAt}, nil
+}
+
+// SaveFeed implements Store
+func (s *SQLiteStore) SaveFeed(ctx context.Context, token string, feed *Feed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.touchToken.ExecContext(ctx, time.Now(), token); err != nil {
+		return fmt.Errorf("could not update token: %w", err)
+	}
+
+	for format, content := range feed.Representations.all() {
+		if _, err := s.upsertCache.ExecContext(ctx, token, format, content, contentETag(content), feed.ExpiresAt); err != nil {
+			return fmt.Errorf("could not cache %s representation: %w", format, err)
+		}
+	}
+
+	entriesJSON, err := encodeStoredEntries(feed.Entries)
+	if err != nil {
+		return fmt.Errorf("could not encode entries: %w", err)
+	}
+	if _, err := s.upsertCache.ExecContext(ctx, token, storageFormatEntries, entriesJSON, contentETag(entriesJSON), feed.ExpiresAt); err != nil {
+		return fmt.Errorf("could not cache entries: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired implements Store
+func (s *SQLiteStore) PurgeExpired(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.purgeCache.ExecContext(ctx, time.Now()); err != nil {
+		return fmt.Errorf("could not purge expired feed cache: %w", err)
+	}
+	return nil
+}
+
+// RecordMessageID implements Store
+func (s *SQLiteStore) RecordMessageID(ctx context.Context, token, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.updateMessageID.ExecContext(ctx, messageID, token); err != nil {
+		return fmt.Errorf("could not record message id: %w", err)
+	}
+	return nil
+}
+
+func contentETag(content string) string {
+	h := sha1.New()
+	h.Write([]byte(content))
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
+}
+
+// storageFormatEntries is a feed_cache format key of its own, distinct
+// from the public formatICS/RSS/Atom/JSON keys: it holds Entries
+// round-tripped through storedEntry rather than Entry's public JSON
+// tags, so UID and ETag (both json:"-" on Entry, since they're not part
+// of the public .json feed) survive a save/load cycle.
+const storageFormatEntries = "entries"
+
+// storedEntry mirrors Entry but with UID and ETag included in its JSON,
+// for internal storage only.
+type storedEntry struct {
+	DateStart   time.Time `json:"dateStart"`
+	DateEnd     time.Time `json:"dateEnd"`
+	Description string    `json:"description"`
+	UID         string    `json:"uid"`
+	ETag        string    `json:"etag"`
+}
+
+func encodeStoredEntries(entries Entries) (string, error) {
+	stored := make([]storedEntry, len(entries))
+	for i, entry := range entries {
+		stored[i] = storedEntry{
+			DateStart:   entry.DateStart,
+			DateEnd:     entry.DateEnd,
+			Description: entry.Description,
+			UID:         entry.UID,
+			ETag:        entry.ETag,
+		}
+	}
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func decodeStoredEntries(raw string) (Entries, error) {
+	var stored []storedEntry
+	if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+		return nil, err
+	}
+	entries := make(Entries, len(stored))
+	for i, s := range stored {
+		entries[i] = &Entry{
+			DateStart:   s.DateStart,
+			DateEnd:     s.DateEnd,
+			Description: s.Description,
+			UID:         s.UID,
+			ETag:        s.ETag,
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("could not open test store: %v", err)
+	}
+	return store
+}
+
+func TestSaveFeedGetFeedRoundTripsEntries(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	token, err := store.CreateToken(ctx, TokenOptions{TZID: "UTC", Country: "us", Sources: []string{"work"}})
+	if err != nil {
+		t.Fatalf("could not create token: %v", err)
+	}
+
+	entries := Entries{
+		{DateStart: time.Now(), DateEnd: time.Now().Add(time.Hour), Description: "standup", UID: "uid-1", ETag: `"etag-1"`},
+	}
+	feed := &Feed{Entries: entries, TZID: "UTC", Country: "us", Sources: []string{"work"}, ExpiresAt: time.Now().Add(time.Hour)}
+	reps, err := renderRepresentations(token, entries, time.UTC, "UTC")
+	if err != nil {
+		t.Fatalf("could not render representations: %v", err)
+	}
+	feed.Representations = reps
+	if err := store.SaveFeed(ctx, token, feed); err != nil {
+		t.Fatalf("could not save feed: %v", err)
+	}
+
+	loaded, err := store.GetFeed(ctx, token)
+	if err != nil {
+		t.Fatalf("could not load feed: %v", err)
+	}
+	if len(loaded.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(loaded.Entries))
+	}
+	if loaded.Entries[0].UID != "uid-1" {
+		t.Errorf("expected UID to survive the round-trip, got %q", loaded.Entries[0].UID)
+	}
+	if loaded.Entries[0].ETag != `"etag-1"` {
+		t.Errorf("expected ETag to survive the round-trip, got %q", loaded.Entries[0].ETag)
+	}
+}
+
+func TestGetFeedAfterPurgeExpiredStillFindsToken(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	token, err := store.CreateToken(ctx, TokenOptions{TZID: "UTC", Country: "us", Sources: []string{"work"}})
+	if err != nil {
+		t.Fatalf("could not create token: %v", err)
+	}
+
+	entries := Entries{{DateStart: time.Now(), DateEnd: time.Now().Add(time.Hour), Description: "standup", UID: "uid-1", ETag: `"etag-1"`}}
+	reps, err := renderRepresentations(token, entries, time.UTC, "UTC")
+	if err != nil {
+		t.Fatalf("could not render representations: %v", err)
+	}
+	feed := &Feed{Representations: reps, Entries: entries, TZID: "UTC", Country: "us", Sources: []string{"work"}, ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := store.SaveFeed(ctx, token, feed); err != nil {
+		t.Fatalf("could not save feed: %v", err)
+	}
+
+	if err := store.PurgeExpired(ctx); err != nil {
+		t.Fatalf("could not purge: %v", err)
+	}
+
+	loaded, err := store.GetFeed(ctx, token)
+	if err != nil {
+		t.Fatalf("expected GetFeed to succeed for a token whose cache was purged but whose tokens row survives, got error: %v", err)
+	}
+	if !loaded.ExpiresAt.Before(time.Now()) {
+		t.Errorf("expected ExpiresAt to read as already expired so callers refresh, got %s", loaded.ExpiresAt)
+	}
+	if len(loaded.Entries) != 0 {
+		t.Errorf("expected no entries for a purged cache, got %d", len(loaded.Entries))
+	}
+}
+
+func TestGetFeedUnknownTokenReturnsErrTokenNotFound(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := store.GetFeed(context.Background(), "does-not-exist"); err != ErrTokenNotFound {
+		t.Errorf("GetFeed for an unknown token = %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestRecordMessageIDIsReflectedInListSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	token, err := store.CreateToken(ctx, TokenOptions{TZID: "UTC", Email: "a@example.com", Schedule: "daily", Country: "us", Sources: []string{"work"}})
+	if err != nil {
+		t.Fatalf("could not create token: %v", err)
+	}
+
+	if err := store.RecordMessageID(ctx, token, "<abc@example-go-ical>"); err != nil {
+		t.Fatalf("could not record message id: %v", err)
+	}
+
+	subs, err := store.ListSubscriptions(ctx)
+	if err != nil {
+		t.Fatalf("could not list subscriptions: %v", err)
+	}
+	if len(subs) != 1 || subs[0].LastMessageID != "<abc@example-go-ical>" {
+		t.Errorf("expected recorded message id to show up in ListSubscriptions, got %+v", subs)
+	}
+}
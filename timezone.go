@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jordic/goics"
+	"time"
+)
+
+// defaultTZID is used whenever a token doesn't specify a ?tz= preference.
+const defaultTZID = "UTC"
+
+// icalLocalFormat renders a local (non-UTC) date-time the way RFC 5545
+// expects it next to a TZID parameter, e.g. "20240115T090000".
+const icalLocalFormat = "20060102T150405"
+
+// supportedTimezones is the configurable set of IANA zones this server
+// is willing to generate VTIMEZONE blocks for.
+var supportedTimezones = []string{
+	"UTC",
+	"America/New_York",
+	"America/Los_Angeles",
+	"Europe/Berlin",
+	"Europe/London",
+}
+
+// resolveTimezone validates tzid against supportedTimezones and loads it.
+func resolveTimezone(tzid string) (*time.Location, error) {
+	for _, candidate := range supportedTimezones {
+		if candidate == tzid {
+			return time.LoadLocation(tzid)
+		}
+	}
+	return nil, fmt.Errorf("unsupported timezone: %s", tzid)
+}
+
+// tzEntries adapts Entries for goics, serializing DTSTART/DTEND in a
+// given zone and attaching a matching VTIMEZONE component.
+type tzEntries struct {
+	Entries Entries
+	Loc     *time.Location
+	TZID    string
+}
+
+// EmitICal implements the interface for goics
+func (e tzEntries) EmitICal() goics.Componenter {
+	c := goics.NewComponent()
+	c.SetType("VCALENDAR")
+	c.AddProperty("CALSCAL", "GREGORIAN")
+
+	if e.TZID != "UTC" {
+		c.AddComponent(buildVTimezone(e.Loc, e.TZID, time.Now().In(e.Loc).Year()))
+	}
+
+	for _, entry := range e.Entries {
+		s := goics.NewComponent()
+		s.SetType("VEVENT")
+		k, v := formatDateTimeField("DTEND", entry.DateEnd, e.Loc, e.TZID)
+		s.AddProperty(k, v)
+		k, v = formatDateTimeField("DTSTART", entry.DateStart, e.Loc, e.TZID)
+		s.AddProperty(k, v)
+		s.AddProperty("SUMMARY", entry.Description)
+		if entry.UID != "" {
+			s.AddProperty("UID", entry.UID)
+		}
+		c.AddComponent(s)
+	}
+	return c
+}
+
+// formatDateTimeField renders a DTSTART/DTEND field for a given zone.
+// UTC is written as an explicit UTC instant ("...Z"), via goics.FormatDateTime
+// rather than FormatDateTimeField, which formats in the local Go process's
+// zone with no "Z" and no TZID, i.e. a floating time; every other zone is
+// written in local time with an explicit TZID parameter, per RFC 5545.
+func formatDateTimeField(name string, t time.Time, loc *time.Location, tzid string) (string, string) {
+	if tzid == "" || tzid == "UTC" {
+		return goics.FormatDateTime(name, t)
+	}
+	return name + ";TZID=" + tzid, t.In(loc).Format(icalLocalFormat)
+}
+
+// buildVTimezone generates a VTIMEZONE component with STANDARD and, if
+// the zone observes DST, a DAYLIGHT sub-component, derived from the
+// actual offset transitions time.Location reports for the given year.
+func buildVTimezone(loc *time.Location, tzid string, year int) goics.Componenter {
+	tz := goics.NewComponent()
+	tz.SetType("VTIMEZONE")
+	tz.AddProperty("TZID", tzid)
+
+	for _, t := range findTransitions(loc, year) {
+		sub := goics.NewComponent()
+		if t.IsDST {
+			sub.SetType("DAYLIGHT")
+		} else {
+			sub.SetType("STANDARD")
+		}
+		sub.AddProperty("DTSTART", t.LocalStart.Format(icalLocalFormat))
+		sub.AddProperty("TZOFFSETFROM", formatOffset(t.OffsetFrom))
+		sub.AddProperty("TZOFFSETTO", formatOffset(t.OffsetTo))
+		sub.AddProperty("TZNAME", t.Name)
+		sub.AddProperty("RRULE", nthWeekdayRRule(t.LocalStart))
+		tz.AddComponent(sub)
+	}
+
+	return tz
+}
+
+// tzTransition describes a single offset change a zone undergoes in a
+// given year, e.g. the spring-forward or fall-back instant.
+type tzTransition struct {
+	LocalStart time.Time
+	OffsetFrom int
+	OffsetTo   int
+	Name       string
+	IsDST      bool
+}
+
+// findTransitions scans a year hour by hour for UTC offset changes.
+// An hour-wide step is plenty of precision for the RRULE this produces,
+// since DST transitions always land on a whole hour in practice.
+func findTransitions(loc *time.Location, year int) []tzTransition {
+	var transitions []tzTransition
+
+	cursor := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	_, prevOffset := cursor.Zone()
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc)
+
+	for cursor.Before(end) {
+		next := cursor.Add(time.Hour)
+		name, offset := next.Zone()
+		if offset != prevOffset {
+			transitions = append(transitions, tzTransition{
+				LocalStart: next,
+				OffsetFrom: prevOffset,
+				OffsetTo:   offset,
+				Name:       name,
+				IsDST:      offset > prevOffset,
+			})
+			prevOffset = offset
+		}
+		cursor = next
+	}
+
+	return transitions
+}
+
+// formatOffset renders a UTC offset in seconds as "+0100"/"-0500".
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, (seconds%3600)/60)
+}
+
+// nthWeekdayRRule expresses a transition date as a yearly RRULE on the
+// nth occurrence of its weekday in its month, e.g. "FREQ=YEARLY;BYMONTH=3;
+// BYDAY=2SU" for the second Sunday in March. The 5th occurrence is
+// expressed as -1 (last), matching how DST rules are usually specified.
+func nthWeekdayRRule(t time.Time) string {
+	weekdayCodes := [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+	nth := (t.Day()-1)/7 + 1
+	if t.Day()+7 > daysInMonth(t.Year(), t.Month()) {
+		nth = -1
+	}
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(t.Month()), nth, weekdayCodes[int(t.Weekday())])
+}
+
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindTransitionsNewYork(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("could not load America/New_York: %v", err)
+	}
+
+	transitions := findTransitions(loc, 2024)
+	if len(transitions) != 2 {
+		t.Fatalf("expected 2 transitions (spring forward, fall back), got %d", len(transitions))
+	}
+
+	spring := transitions[0]
+	if !spring.IsDST {
+		t.Errorf("expected first transition to be spring-forward into DST, got IsDST=false")
+	}
+	if spring.OffsetFrom != -5*3600 || spring.OffsetTo != -4*3600 {
+		t.Errorf("expected offset -5h -> -4h, got %d -> %d", spring.OffsetFrom, spring.OffsetTo)
+	}
+	if spring.LocalStart.Month() != time.March {
+		t.Errorf("expected spring-forward in March, got %s", spring.LocalStart.Month())
+	}
+
+	fall := transitions[1]
+	if fall.IsDST {
+		t.Errorf("expected second transition to be fall-back out of DST, got IsDST=true")
+	}
+	if fall.OffsetFrom != -4*3600 || fall.OffsetTo != -5*3600 {
+		t.Errorf("expected offset -4h -> -5h, got %d -> %d", fall.OffsetFrom, fall.OffsetTo)
+	}
+	if fall.LocalStart.Month() != time.November {
+		t.Errorf("expected fall-back in November, got %s", fall.LocalStart.Month())
+	}
+}
+
+func TestFindTransitionsUTC(t *testing.T) {
+	transitions := findTransitions(time.UTC, 2024)
+	if len(transitions) != 0 {
+		t.Errorf("expected no transitions for UTC, got %d", len(transitions))
+	}
+}
+
+func TestNthWeekdayRRule(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"second Sunday in March", time.Date(2024, time.March, 10, 2, 0, 0, 0, time.UTC), "FREQ=YEARLY;BYMONTH=3;BYDAY=2SU"},
+		{"first Sunday in November", time.Date(2024, time.November, 3, 2, 0, 0, 0, time.UTC), "FREQ=YEARLY;BYMONTH=11;BYDAY=1SU"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nthWeekdayRRule(tt.t); got != tt.want {
+				t.Errorf("nthWeekdayRRule(%s) = %q, want %q", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{3600, "+0100"},
+		{-5 * 3600, "-0500"},
+		{0, "+0000"},
+		{-4*3600 - 30*60, "-0430"},
+	}
+	for _, tt := range tests {
+		if got := formatOffset(tt.seconds); got != tt.want {
+			t.Errorf("formatOffset(%d) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}